@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// backendStatus is the JSON shape returned by /lb/health for one backend.
+type backendStatus struct {
+	URL      string  `json:"url"`
+	Alive    bool    `json:"alive"`
+	Draining bool    `json:"draining"`
+	Inflight int64   `json:"inflight"`
+	EWMASecs float64 `json:"ewma_seconds"`
+}
+
+// healthStatusHandler serves /lb/health with the current status of every backend.
+func healthStatusHandler(w http.ResponseWriter, r *http.Request) {
+	backends := pool.Backends()
+	statuses := make([]backendStatus, 0, len(backends))
+	for _, b := range backends {
+		statuses = append(statuses, backendStatus{
+			URL:      b.URL.String(),
+			Alive:    b.IsAlive(),
+			Draining: b.IsDraining(),
+			Inflight: b.Inflight(),
+			EWMASecs: b.EWMA(),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
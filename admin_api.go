@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// addBackendRequest is the JSON body accepted by POST /backends.
+type addBackendRequest struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// patchBackendRequest is the JSON body accepted by PATCH /backends.
+// Down is a pointer so that omitting it leaves the backend's alive state
+// untouched, distinguishing "not provided" from "explicitly false".
+type patchBackendRequest struct {
+	Weight int   `json:"weight"`
+	Down   *bool `json:"down"`
+}
+
+// NewAdminMux builds the mux served on -admin-addr, separate from the
+// data-plane listener so that backend management never competes with
+// proxied traffic.
+//
+// DELETE and PATCH identify the backend via a "url" query parameter
+// rather than a path segment: backend identifiers are full URLs like
+// http://127.0.0.1:3031, and http.ServeMux path-cleans "//" out of the
+// request path before routing, which would otherwise mangle the scheme
+// and 301 every request away from the handler.
+func NewAdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/backends", handleBackends)
+	return mux
+}
+
+// handleBackends serves POST /backends to add a new backend, and DELETE
+// /backends?url=... / PATCH /backends?url=... to manage an existing one.
+func handleBackends(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		handleAddBackend(w, r)
+	case http.MethodDelete, http.MethodPatch:
+		handleBackendResource(w, r)
+	default:
+		w.Header().Set("Allow", http.MethodPost+", "+http.MethodDelete+", "+http.MethodPatch)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleAddBackend(w http.ResponseWriter, r *http.Request) {
+	var req addBackendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.URL == "" {
+		http.Error(w, "url is required", http.StatusBadRequest)
+		return
+	}
+	if pool.Find(req.URL) != nil {
+		http.Error(w, "backend already exists", http.StatusConflict)
+		return
+	}
+
+	addBackend(req.URL, req.Weight)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleBackendResource serves DELETE and PATCH for the backend named by
+// the "url" query parameter.
+func handleBackendResource(w http.ResponseWriter, r *http.Request) {
+	raw := r.URL.Query().Get("url")
+	if raw == "" {
+		http.Error(w, "url query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	b := pool.Find(raw)
+	if b == nil {
+		http.Error(w, "backend not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		drainAndRemove(b)
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPatch:
+		var req patchBackendRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Weight > 0 {
+			b.SetWeight(req.Weight)
+		}
+		if req.Down != nil {
+			b.SetAlive(!*req.Down)
+			if healthChecker != nil {
+				healthChecker.refreshAliveGauge()
+			}
+			log.Printf("backend %s: marked down=%v via admin API", b.URL, *req.Down)
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// drainAndRemove marks b as draining so the balancer stops sending it new
+// requests, then removes it from the pool once its in-flight requests
+// have finished.
+func drainAndRemove(b *Backend) {
+	b.SetDraining(true)
+	log.Printf("backend draining: %s", b.URL)
+
+	go func() {
+		for b.Inflight() > 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		pool.RemoveBackend(b)
+		log.Printf("backend removed: %s", b.URL)
+	}()
+}
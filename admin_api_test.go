@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAdminAPIBackendLifecycleThroughServeMux(t *testing.T) {
+	withPoolBackends(t)
+
+	srv := httptest.NewServer(NewAdminMux())
+	t.Cleanup(srv.Close)
+
+	// http.Client would silently follow a 301 from ServeMux's path
+	// cleaning and mask the bug; refuse to follow redirects so a
+	// regression shows up as an unexpected status instead.
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	const backendURL = "http://127.0.0.1:39991"
+
+	addBody, err := json.Marshal(addBackendRequest{URL: backendURL, Weight: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Post(srv.URL+"/backends", "application/json", bytes.NewReader(addBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /backends status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if pool.Find(backendURL) == nil {
+		t.Fatal("backend missing from pool after POST /backends")
+	}
+
+	patchBody, err := json.Marshal(patchBackendRequest{Weight: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	patchURL := srv.URL + "/backends?url=" + url.QueryEscape(backendURL)
+	req, err := http.NewRequest(http.MethodPatch, patchURL, bytes.NewReader(patchBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH %s status = %d, want %d (a 301 here means ServeMux's path cleaning mangled the URL)", patchURL, resp.StatusCode, http.StatusOK)
+	}
+	if b := pool.Find(backendURL); b == nil || b.Weight() != 7 {
+		t.Fatalf("backend after PATCH = %+v, want weight 7", b)
+	}
+
+	req, err = http.NewRequest(http.MethodDelete, patchURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("DELETE %s status = %d, want %d", patchURL, resp.StatusCode, http.StatusAccepted)
+	}
+}
+
+func TestAdminAPIPatchMarksBackendDown(t *testing.T) {
+	b := newHedgeTestBackend(t, http.StatusOK, 0)
+	withPoolBackends(t, b)
+
+	srv := httptest.NewServer(NewAdminMux())
+	t.Cleanup(srv.Close)
+
+	down := true
+	body, err := json.Marshal(patchBackendRequest{Down: &down})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPatch, srv.URL+"/backends?url="+url.QueryEscape(b.URL.String()), bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if b.IsAlive() {
+		t.Fatal("backend still alive after PATCH down=true")
+	}
+}
+
+func TestAdminAPIResourceRequiresURLParam(t *testing.T) {
+	withPoolBackends(t)
+
+	srv := httptest.NewServer(NewAdminMux())
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodDelete, srv.URL+"/backends", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("DELETE /backends with no url param status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
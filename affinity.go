@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const affinityCookieName = "LB_AFFINITY"
+
+// signAffinity returns an HMAC-signed cookie value binding backendURL to
+// secret, so a returning client's cookie can be trusted without server-side
+// session storage.
+func signAffinity(secret, backendURL string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(backendURL))
+	return base64.RawURLEncoding.EncodeToString([]byte(backendURL)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyAffinity checks cookie against secret and returns the backend URL
+// it was signed for, or ok=false if the cookie is malformed or tampered with.
+func verifyAffinity(secret, cookie string) (backendURL string, ok bool) {
+	i := strings.LastIndex(cookie, ".")
+	if i < 0 {
+		return "", false
+	}
+	urlPart, sigPart := cookie[:i], cookie[i+1:]
+
+	urlBytes, err := base64.RawURLEncoding.DecodeString(urlPart)
+	if err != nil {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		return "", false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(urlBytes)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", false
+	}
+	return string(urlBytes), true
+}
+
+// stickyCookieBalancer routes a request back to the backend named in its
+// LB_AFFINITY cookie, as long as that backend is still alive and not
+// draining, and otherwise falls back to fallback's strategy (tagging the
+// response with a fresh cookie so the client sticks from then on).
+type stickyCookieBalancer struct {
+	pool     *ServerPool
+	secret   string
+	fallback Balancer
+}
+
+func (sb *stickyCookieBalancer) Pick(r *http.Request) *Backend {
+	if c, err := r.Cookie(affinityCookieName); err == nil {
+		if backendURL, ok := verifyAffinity(sb.secret, c.Value); ok {
+			if b := sb.pool.Find(backendURL); b != nil && b.IsAlive() && !b.IsDraining() {
+				return trackPick(b)
+			}
+		}
+	}
+	return sb.fallback.Pick(r)
+}
+
+func (sb *stickyCookieBalancer) Release(b *Backend, err error, dur time.Duration) {
+	sb.fallback.Release(b, err, dur)
+}
+
+// setCookie tags resp so the client sticks to b on subsequent requests.
+// Called from addBackend's ModifyResponse via the optional cookieSetter
+// interface, since Balancer.Pick has no access to the response.
+func (sb *stickyCookieBalancer) setCookie(resp *http.Response, b *Backend) {
+	cookie := &http.Cookie{
+		Name:     affinityCookieName,
+		Value:    signAffinity(sb.secret, b.URL.String()),
+		Path:     "/",
+		HttpOnly: true,
+	}
+	resp.Header.Add("Set-Cookie", cookie.String())
+}
+
+// cookieSetter is implemented by balancers that want to tag responses with
+// an affinity cookie once a backend has been chosen.
+type cookieSetter interface {
+	setCookie(resp *http.Response, b *Backend)
+}
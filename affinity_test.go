@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSignVerifyAffinityRoundTrip(t *testing.T) {
+	const secret = "s3cr3t"
+	cookie := signAffinity(secret, "http://backend-a")
+
+	got, ok := verifyAffinity(secret, cookie)
+	if !ok {
+		t.Fatalf("verifyAffinity(%q) = ok=false, want true", cookie)
+	}
+	if got != "http://backend-a" {
+		t.Fatalf("verifyAffinity(%q) = %q, want http://backend-a", cookie, got)
+	}
+}
+
+func TestVerifyAffinityRejectsTamperedURL(t *testing.T) {
+	const secret = "s3cr3t"
+	cookieA := signAffinity(secret, "http://backend-a")
+	cookieB := signAffinity(secret, "http://backend-b")
+
+	// Splice backend-b's URL half onto backend-a's signature half, as a
+	// client editing the cookie to redirect itself might try.
+	forged := cookieB[:strings.LastIndex(cookieB, ".")] + cookieA[strings.LastIndex(cookieA, "."):]
+
+	if _, ok := verifyAffinity(secret, forged); ok {
+		t.Fatal("verifyAffinity(forged) = ok=true, want false (signature mismatch)")
+	}
+}
+
+func TestVerifyAffinityRejectsWrongSecret(t *testing.T) {
+	cookie := signAffinity("secret-a", "http://backend-a")
+	if _, ok := verifyAffinity("secret-b", cookie); ok {
+		t.Fatal("verifyAffinity with wrong secret = ok=true, want false")
+	}
+}
+
+func TestVerifyAffinityRejectsMalformedCookie(t *testing.T) {
+	for _, bad := range []string{"", "no-dot-here", "..", "!!!.!!!"} {
+		if _, ok := verifyAffinity("secret", bad); ok {
+			t.Fatalf("verifyAffinity(%q) = ok=true, want false", bad)
+		}
+	}
+}
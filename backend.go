@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ewmaAlpha is the smoothing factor used to fold new latency samples
+// into a Backend's EWMA: ewma = alpha*sample + (1-alpha)*ewma.
+const ewmaAlpha = 0.2
+
+// Backend represents a single upstream server together with the live
+// stats (in-flight count, response-time EWMA) that balancer strategies
+// use to score it.
+type Backend struct {
+	URL          *url.URL
+	ReverseProxy *httputil.ReverseProxy
+
+	// healthPath overrides the health checker's global -healthcheck-path
+	// for this backend when non-empty, set via -config's health_check_path
+	// and reconcilable on a hot reload; see HealthPath/SetHealthPath.
+	healthPath string
+
+	weight int64 // atomic; see Weight/SetWeight
+
+	stateMux sync.RWMutex
+	alive    bool
+	draining bool // true once the admin API has asked to remove this backend
+
+	inflight int64 // atomic; requests currently proxied to this backend
+
+	ewmaMux sync.Mutex
+	ewma    float64 // seconds; 0 until the first sample arrives
+
+	breaker circuitBreaker
+}
+
+// Weight returns the backend's weighted-round-robin weight, defaulting
+// to 1 if never set.
+func (b *Backend) Weight() int {
+	if w := atomic.LoadInt64(&b.weight); w > 0 {
+		return int(w)
+	}
+	return 1
+}
+
+// SetWeight updates the backend's weighted-round-robin weight.
+func (b *Backend) SetWeight(w int) {
+	atomic.StoreInt64(&b.weight, int64(w))
+}
+
+func (b *Backend) SetAlive(alive bool) {
+	b.stateMux.Lock()
+	b.alive = alive
+	b.stateMux.Unlock()
+}
+
+func (b *Backend) IsAlive() bool {
+	b.stateMux.RLock()
+	defer b.stateMux.RUnlock()
+	return b.alive
+}
+
+// SetDraining marks a backend as being removed by the admin API: it
+// stays alive for in-flight requests but is no longer picked for new ones.
+func (b *Backend) SetDraining(draining bool) {
+	b.stateMux.Lock()
+	b.draining = draining
+	b.stateMux.Unlock()
+}
+
+func (b *Backend) IsDraining() bool {
+	b.stateMux.RLock()
+	defer b.stateMux.RUnlock()
+	return b.draining
+}
+
+// HealthPath returns the backend's per-backend health-check path, or ""
+// if it uses the health checker's global path.
+func (b *Backend) HealthPath() string {
+	b.stateMux.RLock()
+	defer b.stateMux.RUnlock()
+	return b.healthPath
+}
+
+// SetHealthPath updates the backend's per-backend health-check path, so
+// -config reloads can change it without removing and re-adding the backend.
+func (b *Backend) SetHealthPath(path string) {
+	b.stateMux.Lock()
+	b.healthPath = path
+	b.stateMux.Unlock()
+}
+
+// Inflight returns the number of requests currently dispatched to this backend.
+func (b *Backend) Inflight() int64 {
+	return atomic.LoadInt64(&b.inflight)
+}
+
+func (b *Backend) incInflight() {
+	atomic.AddInt64(&b.inflight, 1)
+}
+
+func (b *Backend) decInflight() {
+	atomic.AddInt64(&b.inflight, -1)
+}
+
+// EWMA returns the current exponentially-weighted moving average of
+// response times, in seconds.
+func (b *Backend) EWMA() float64 {
+	b.ewmaMux.Lock()
+	defer b.ewmaMux.Unlock()
+	return b.ewma
+}
+
+// observe folds a new latency sample into the EWMA.
+func (b *Backend) observe(dur time.Duration) {
+	sample := dur.Seconds()
+	b.ewmaMux.Lock()
+	if b.ewma == 0 {
+		b.ewma = sample
+	} else {
+		b.ewma = ewmaAlpha*sample + (1-ewmaAlpha)*b.ewma
+	}
+	b.ewmaMux.Unlock()
+}
+
+// score ranks a backend by load*latency for strategies like p2c.
+// Backends with no samples yet score 0 so they get tried at least once.
+func (b *Backend) score() float64 {
+	return float64(b.Inflight()) * b.EWMA()
+}
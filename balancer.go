@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Kunj125/load_balancer_go/metrics"
+)
+
+// Balancer picks a backend for an incoming request and is notified when
+// that request finishes so it can update its internal scoring.
+type Balancer interface {
+	// Pick selects an alive backend for r, or nil if none are available.
+	Pick(r *http.Request) *Backend
+	// Release reports that a request dispatched to b has finished, with
+	// err set if the backend failed and dur set to how long it took.
+	Release(b *Backend, err error, dur time.Duration)
+}
+
+// BalancerOptions carries the flags only some strategies need, so
+// NewBalancer's signature doesn't grow with every new strategy.
+type BalancerOptions struct {
+	AffinitySecret string  // -affinity-secret, required by sticky-cookie
+	HashKey        string  // -hash-key, used by consistent-hash
+	HashEpsilon    float64 // -hash-epsilon, used by consistent-hash
+}
+
+// NewBalancer builds the Balancer selected by -strategy.
+func NewBalancer(strategy string, pool *ServerPool, opts BalancerOptions) (Balancer, error) {
+	switch strategy {
+	case "", "roundrobin":
+		return &roundRobinBalancer{pool: pool}, nil
+	case "weighted-rr":
+		return &weightedRoundRobinBalancer{pool: pool, state: map[*Backend]*int{}}, nil
+	case "least-conn":
+		return &leastConnBalancer{pool: pool}, nil
+	case "ewma":
+		return &ewmaBalancer{pool: pool}, nil
+	case "p2c":
+		return &p2cBalancer{pool: pool}, nil
+	case "sticky-cookie":
+		if opts.AffinitySecret == "" {
+			return nil, errors.New("-strategy=sticky-cookie requires -affinity-secret")
+		}
+		return &stickyCookieBalancer{pool: pool, secret: opts.AffinitySecret, fallback: &leastConnBalancer{pool: pool}}, nil
+	case "consistent-hash":
+		return &consistentHashBalancer{pool: pool, virtualNodes: defaultVirtualNodes, key: parseHashKey(opts.HashKey), epsilon: opts.HashEpsilon}, nil
+	default:
+		return nil, errors.New("unknown strategy: " + strategy)
+	}
+}
+
+// trackPick and trackRelease centralize the inflight/EWMA bookkeeping
+// shared by every strategy below.
+func trackPick(b *Backend) *Backend {
+	if b != nil {
+		b.incInflight()
+		metrics.Inflight.WithLabelValues(b.URL.String()).Set(float64(b.Inflight()))
+	}
+	return b
+}
+
+func trackRelease(b *Backend, dur time.Duration) {
+	if b == nil {
+		return
+	}
+	b.decInflight()
+	metrics.Inflight.WithLabelValues(b.URL.String()).Set(float64(b.Inflight()))
+	if dur > 0 {
+		b.observe(dur)
+	}
+}
+
+// roundRobinBalancer cycles through alive backends in order.
+type roundRobinBalancer struct {
+	pool    *ServerPool
+	current uint64
+}
+
+func (rb *roundRobinBalancer) Pick(r *http.Request) *Backend {
+	backends := rb.pool.Backends()
+	length := len(backends)
+	if length == 0 {
+		return nil
+	}
+	next := int(atomic.AddUint64(&rb.current, 1) % uint64(length))
+	for i := 0; i < length; i++ {
+		idx := (next + i) % length
+		if backends[idx].IsAlive() {
+			return trackPick(backends[idx])
+		}
+	}
+	return nil
+}
+
+func (rb *roundRobinBalancer) Release(b *Backend, err error, dur time.Duration) {
+	trackRelease(b, dur)
+}
+
+// weightedRoundRobinBalancer implements smooth weighted round robin: each
+// pick adds every backend's weight to its running total, selects the
+// backend with the highest total, then subtracts the sum of all weights
+// from the selected backend's total.
+type weightedRoundRobinBalancer struct {
+	pool *ServerPool
+
+	mu    sync.Mutex
+	state map[*Backend]*int // current weight per backend
+}
+
+func (wb *weightedRoundRobinBalancer) Pick(r *http.Request) *Backend {
+	backends := wb.pool.AliveBackends()
+	if len(backends) == 0 {
+		return nil
+	}
+
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	var selected *Backend
+	var selectedCurrent *int
+	total := 0
+	for _, b := range backends {
+		weight := b.Weight()
+		current := wb.currentWeightLocked(b)
+		*current += weight
+		total += weight
+		if selected == nil || *current > *selectedCurrent {
+			selected = b
+			selectedCurrent = current
+		}
+	}
+	*selectedCurrent -= total
+	return trackPick(selected)
+}
+
+func (wb *weightedRoundRobinBalancer) currentWeightLocked(b *Backend) *int {
+	current, ok := wb.state[b]
+	if !ok {
+		current = new(int)
+		wb.state[b] = current
+	}
+	return current
+}
+
+func (wb *weightedRoundRobinBalancer) Release(b *Backend, err error, dur time.Duration) {
+	trackRelease(b, dur)
+}
+
+// leastConnBalancer picks the alive backend with the fewest in-flight requests.
+type leastConnBalancer struct {
+	pool *ServerPool
+}
+
+func (lb *leastConnBalancer) Pick(r *http.Request) *Backend {
+	var best *Backend
+	for _, b := range lb.pool.AliveBackends() {
+		if best == nil || b.Inflight() < best.Inflight() {
+			best = b
+		}
+	}
+	return trackPick(best)
+}
+
+func (lb *leastConnBalancer) Release(b *Backend, err error, dur time.Duration) {
+	trackRelease(b, dur)
+}
+
+// ewmaBalancer picks the alive backend with the lowest response-time EWMA.
+type ewmaBalancer struct {
+	pool *ServerPool
+}
+
+func (eb *ewmaBalancer) Pick(r *http.Request) *Backend {
+	var best *Backend
+	for _, b := range eb.pool.AliveBackends() {
+		if best == nil || b.EWMA() < best.EWMA() {
+			best = b
+		}
+	}
+	return trackPick(best)
+}
+
+func (eb *ewmaBalancer) Release(b *Backend, err error, dur time.Duration) {
+	trackRelease(b, dur)
+}
+
+// p2cBalancer implements power-of-two-choices: it samples two random
+// alive backends and picks the one with the lower inflight*ewma score.
+type p2cBalancer struct {
+	pool *ServerPool
+}
+
+func (pb *p2cBalancer) Pick(r *http.Request) *Backend {
+	backends := pb.pool.AliveBackends()
+	switch len(backends) {
+	case 0:
+		return nil
+	case 1:
+		return trackPick(backends[0])
+	}
+
+	i := rand.Intn(len(backends))
+	j := rand.Intn(len(backends) - 1)
+	if j >= i {
+		j++
+	}
+
+	a, c := backends[i], backends[j]
+	if a.score() <= c.score() {
+		return trackPick(a)
+	}
+	return trackPick(c)
+}
+
+func (pb *p2cBalancer) Release(b *Backend, err error, dur time.Duration) {
+	trackRelease(b, dur)
+}
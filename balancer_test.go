@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestBackend(t *testing.T, rawURL string, weight int, alive bool) *Backend {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", rawURL, err)
+	}
+	return &Backend{URL: u, weight: int64(weight), alive: alive}
+}
+
+func TestRoundRobinBalancerCyclesAndSkipsDead(t *testing.T) {
+	a := newTestBackend(t, "http://a", 1, true)
+	b := newTestBackend(t, "http://b", 1, false)
+	c := newTestBackend(t, "http://c", 1, true)
+	pool := &ServerPool{backends: []*Backend{a, b, c}}
+	rb := &roundRobinBalancer{pool: pool}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for i := 0; i < 4; i++ {
+		picked := rb.Pick(req)
+		if picked == nil || picked == b {
+			t.Fatalf("Pick() = %v, want a or c (b is dead)", picked)
+		}
+		rb.Release(picked, nil, time.Millisecond)
+	}
+}
+
+func TestRoundRobinBalancerNoBackendsReturnsNil(t *testing.T) {
+	rb := &roundRobinBalancer{pool: &ServerPool{}}
+	if got := rb.Pick(httptest.NewRequest(http.MethodGet, "/", nil)); got != nil {
+		t.Fatalf("Pick() = %v, want nil", got)
+	}
+}
+
+func TestWeightedRoundRobinBalancerFollowsSmoothWRRSequence(t *testing.T) {
+	a := newTestBackend(t, "http://a", 5, true)
+	b := newTestBackend(t, "http://b", 1, true)
+	c := newTestBackend(t, "http://c", 1, true)
+	pool := &ServerPool{backends: []*Backend{a, b, c}}
+	wb := &weightedRoundRobinBalancer{pool: pool, state: map[*Backend]*int{}}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	var seq []*Backend
+	for i := 0; i < 7; i++ {
+		picked := wb.Pick(req)
+		seq = append(seq, picked)
+		wb.Release(picked, nil, 0)
+	}
+	// Standard smooth-WRR sequence for weights 5,1,1: a is picked 5 of
+	// every 7 turns, interleaved rather than bunched at the front.
+	want := []*Backend{a, a, b, a, c, a, a}
+	for i, w := range want {
+		if seq[i] != w {
+			t.Fatalf("pick %d = %v, want %v", i, seq[i], w)
+		}
+	}
+}
+
+func TestLeastConnBalancerPicksFewestInflight(t *testing.T) {
+	a := newTestBackend(t, "http://a", 1, true)
+	b := newTestBackend(t, "http://b", 1, true)
+	a.incInflight()
+	a.incInflight()
+	pool := &ServerPool{backends: []*Backend{a, b}}
+	lb := &leastConnBalancer{pool: pool}
+
+	if got := lb.Pick(httptest.NewRequest(http.MethodGet, "/", nil)); got != b {
+		t.Fatalf("Pick() = %v, want b (fewer inflight)", got)
+	}
+}
+
+func TestEWMABalancerPicksLowestLatency(t *testing.T) {
+	a := newTestBackend(t, "http://a", 1, true)
+	b := newTestBackend(t, "http://b", 1, true)
+	a.observe(100 * time.Millisecond)
+	b.observe(10 * time.Millisecond)
+	pool := &ServerPool{backends: []*Backend{a, b}}
+	eb := &ewmaBalancer{pool: pool}
+
+	if got := eb.Pick(httptest.NewRequest(http.MethodGet, "/", nil)); got != b {
+		t.Fatalf("Pick() = %v, want b (lower EWMA)", got)
+	}
+}
+
+func TestP2CBalancerPrefersLowerScore(t *testing.T) {
+	a := newTestBackend(t, "http://a", 1, true)
+	b := newTestBackend(t, "http://b", 1, true)
+	a.incInflight()
+	a.observe(50 * time.Millisecond)
+	// b has no samples yet, so its score is 0 and it must always win
+	// against a's nonzero inflight*ewma score, regardless of which of
+	// the two candidate slots p2c happens to draw.
+	pool := &ServerPool{backends: []*Backend{a, b}}
+	pb := &p2cBalancer{pool: pool}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for i := 0; i < 20; i++ {
+		if got := pb.Pick(req); got != b {
+			t.Fatalf("Pick() = %v, want b (score 0 beats a's positive score)", got)
+		}
+	}
+}
+
+func TestNewBalancerStickyCookieRequiresSecret(t *testing.T) {
+	if _, err := NewBalancer("sticky-cookie", &pool, BalancerOptions{}); err == nil {
+		t.Fatal("NewBalancer(sticky-cookie) with no secret: want error, got nil")
+	}
+}
+
+func TestNewBalancerUnknownStrategy(t *testing.T) {
+	if _, err := NewBalancer("nonsense", &pool, BalancerOptions{}); err == nil {
+		t.Fatal("NewBalancer(nonsense): want error, got nil")
+	}
+}
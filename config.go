@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// backendTLSSpec carries the TLS options for a backend entry in
+// -config's YAML file, used when connecting to an HTTPS upstream.
+type backendTLSSpec struct {
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	ServerName         string `yaml:"server_name"`
+}
+
+// backendSpec is one backend entry in -config's YAML file.
+type backendSpec struct {
+	URL        string         `yaml:"url"`
+	Weight     int            `yaml:"weight"`
+	HealthPath string         `yaml:"health_check_path"`
+	TLS        backendTLSSpec `yaml:"tls"`
+}
+
+// lbConfig is the shape of the -config YAML file.
+type lbConfig struct {
+	Strategy string        `yaml:"strategy"`
+	Backends []backendSpec `yaml:"backends"`
+}
+
+func loadConfigFile(path string) (*lbConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg lbConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// applyConfig reconciles the pool against cfg: backends in cfg but
+// missing from the pool are added, backends in the pool but no longer
+// in cfg are drained and removed, and weight/health-check path are
+// updated in place for backends that remain. Safe to call repeatedly as
+// cfg is reloaded.
+//
+// TLS options are not reconciled in place: they're baked into the
+// backend's ReverseProxy.Transport at creation, and swapping that field
+// while requests may be in flight isn't safe. Changing tls for an
+// existing backend requires removing it (e.g. via the admin API) and
+// letting the next reload re-add it under the new options.
+func applyConfig(cfg *lbConfig) {
+	desired := make(map[string]backendSpec, len(cfg.Backends))
+	for _, spec := range cfg.Backends {
+		desired[spec.URL] = spec
+	}
+
+	for _, b := range pool.Backends() {
+		key := b.URL.String()
+		if spec, ok := desired[key]; ok {
+			if spec.Weight > 0 {
+				b.SetWeight(spec.Weight)
+			}
+			b.SetHealthPath(spec.HealthPath)
+			delete(desired, key)
+			continue
+		}
+		drainAndRemove(b)
+	}
+
+	for _, spec := range desired {
+		addBackendSpec(spec)
+	}
+
+	log.Printf("config: applied %d backend(s) from config", len(cfg.Backends))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchConfig reloads path into the pool whenever it changes on disk or
+// the process receives SIGHUP. It blocks and should be started in its
+// own goroutine.
+func watchConfig(path string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("config watch: %v", err)
+		return
+	}
+	defer watcher.Close()
+	if err := watcher.Add(path); err != nil {
+		log.Printf("config watch: %v", err)
+		return
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	reload := func() {
+		cfg, err := loadConfigFile(path)
+		if err != nil {
+			log.Printf("config reload: %v", err)
+			return
+		}
+		applyConfig(cfg)
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+				log.Printf("config: %s changed, reloading", path)
+				reload()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("config watch: %v", err)
+		case <-sighup:
+			log.Printf("config: SIGHUP received, reloading")
+			reload()
+		}
+	}
+}
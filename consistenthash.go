@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// defaultVirtualNodes is how many ring positions each backend gets when
+// -strategy=consistent-hash is selected.
+const defaultVirtualNodes = 100
+
+// hashKeySource picks the string a request is hashed on for consistent
+// hashing, as configured by -hash-key.
+type hashKeySource struct {
+	kind   string // "ip", "header", or "path"
+	header string
+}
+
+// parseHashKey parses -hash-key values of "ip", "path", or
+// "header:X-Header-Name". It defaults to "ip" for anything unrecognized.
+func parseHashKey(raw string) hashKeySource {
+	switch {
+	case raw == "path":
+		return hashKeySource{kind: "path"}
+	case strings.HasPrefix(raw, "header:"):
+		return hashKeySource{kind: "header", header: strings.TrimPrefix(raw, "header:")}
+	default:
+		return hashKeySource{kind: "ip"}
+	}
+}
+
+func (k hashKeySource) extract(r *http.Request) string {
+	switch k.kind {
+	case "path":
+		return r.URL.Path
+	case "header":
+		if v := r.Header.Get(k.header); v != "" {
+			return v
+		}
+		return clientIP(r)
+	default:
+		return clientIP(r)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ringEntry is one virtual node on the consistent-hash ring.
+type ringEntry struct {
+	hash    uint64
+	backend *Backend
+}
+
+// buildHashRing places virtualNodes positions per backend on the ring,
+// sorted by hash so Pick can binary-search it.
+func buildHashRing(backends []*Backend, virtualNodes int) []ringEntry {
+	ring := make([]ringEntry, 0, len(backends)*virtualNodes)
+	for _, b := range backends {
+		key := b.URL.String()
+		for i := 0; i < virtualNodes; i++ {
+			ring = append(ring, ringEntry{
+				hash:    xxhash.Sum64String(fmt.Sprintf("%s#%d", key, i)),
+				backend: b,
+			})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	return ring
+}
+
+func averageInflight(backends []*Backend) float64 {
+	if len(backends) == 0 {
+		return 0
+	}
+	var total int64
+	for _, b := range backends {
+		total += b.Inflight()
+	}
+	return float64(total) / float64(len(backends))
+}
+
+// consistentHashBalancer implements consistent hashing over a ring of
+// virtual nodes, with Google's bounded-load rule: a backend more than
+// (1+epsilon) times as loaded as the average is skipped in favor of the
+// next ring position, so no single key can overload one backend.
+type consistentHashBalancer struct {
+	pool         *ServerPool
+	virtualNodes int
+	key          hashKeySource
+	epsilon      float64
+}
+
+func (cb *consistentHashBalancer) Pick(r *http.Request) *Backend {
+	backends := cb.pool.AliveBackends()
+	if len(backends) == 0 {
+		return nil
+	}
+
+	ring := buildHashRing(backends, cb.virtualNodes)
+	h := xxhash.Sum64String(cb.key.extract(r))
+	start := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+
+	threshold := (1 + cb.epsilon) * averageInflight(backends)
+	visited := map[*Backend]bool{}
+	for i := 0; i < len(ring); i++ {
+		b := ring[(start+i)%len(ring)].backend
+		if visited[b] {
+			continue
+		}
+		visited[b] = true
+		if float64(b.Inflight()) <= threshold || len(visited) == len(backends) {
+			return trackPick(b)
+		}
+	}
+	return trackPick(backends[0])
+}
+
+func (cb *consistentHashBalancer) Release(b *Backend, err error, dur time.Duration) {
+	trackRelease(b, dur)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseHashKey(t *testing.T) {
+	cases := map[string]hashKeySource{
+		"ip":           {kind: "ip"},
+		"":             {kind: "ip"},
+		"path":         {kind: "path"},
+		"header:X-Foo": {kind: "header", header: "X-Foo"},
+	}
+	for raw, want := range cases {
+		if got := parseHashKey(raw); got != want {
+			t.Errorf("parseHashKey(%q) = %+v, want %+v", raw, got, want)
+		}
+	}
+}
+
+func TestConsistentHashBalancerSameKeyStableBackend(t *testing.T) {
+	a := newTestBackend(t, "http://a", 1, true)
+	b := newTestBackend(t, "http://b", 1, true)
+	c := newTestBackend(t, "http://c", 1, true)
+	pool := &ServerPool{backends: []*Backend{a, b, c}}
+	cb := &consistentHashBalancer{pool: pool, virtualNodes: defaultVirtualNodes, key: parseHashKey("ip")}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+
+	first := cb.Pick(req)
+	if first == nil {
+		t.Fatal("Pick() = nil, want a backend")
+	}
+	cb.Release(first, nil, 0)
+	for i := 0; i < 10; i++ {
+		got := cb.Pick(req)
+		if got != first {
+			t.Fatalf("Pick() = %v on attempt %d, want stable %v for the same key", got, i, first)
+		}
+		cb.Release(got, nil, 0)
+	}
+}
+
+func TestConsistentHashBalancerBoundedLoadSkipsOverloadedBackend(t *testing.T) {
+	a := newTestBackend(t, "http://a", 1, true)
+	b := newTestBackend(t, "http://b", 1, true)
+	pool := &ServerPool{backends: []*Backend{a, b}}
+	cb := &consistentHashBalancer{pool: pool, virtualNodes: defaultVirtualNodes, key: parseHashKey("ip"), epsilon: 0}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+
+	first := cb.Pick(req)
+	if first == nil {
+		t.Fatal("Pick() = nil, want a backend")
+	}
+	// Pile enough inflight onto the naturally-hashed backend that, with
+	// epsilon=0, bounded load must route the next pick elsewhere instead
+	// of overloading it further.
+	for i := 0; i < 5; i++ {
+		first.incInflight()
+	}
+
+	second := cb.Pick(req)
+	if second == first {
+		t.Fatalf("Pick() = %v again, want bounded load to skip the overloaded backend", second)
+	}
+}
+
+func TestConsistentHashBalancerNoBackendsReturnsNil(t *testing.T) {
+	cb := &consistentHashBalancer{pool: &ServerPool{}, virtualNodes: defaultVirtualNodes, key: parseHashKey("ip")}
+	if got := cb.Pick(httptest.NewRequest(http.MethodGet, "/", nil)); got != nil {
+		t.Fatalf("Pick() = %v, want nil", got)
+	}
+}
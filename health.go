@@ -0,0 +1,169 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/Kunj125/load_balancer_go/metrics"
+)
+
+// Passive circuit-breaker tuning. These trip a backend after repeated
+// proxy errors even if active health checks haven't caught up yet.
+const (
+	failureThreshold = 3
+	failureWindow    = 10 * time.Second
+	initialBackoff   = 1 * time.Second
+	maxBackoff       = 30 * time.Second
+)
+
+// circuitBreaker tracks a rolling failure count for passive detection
+// and the current re-probe backoff once a backend has been tripped.
+type circuitBreaker struct {
+	mu          sync.Mutex
+	failures    int
+	windowStart time.Time
+	backoff     time.Duration
+}
+
+// recordFailure notes a proxy failure for b. If failures cross
+// failureThreshold within failureWindow, b is marked dead and handed to
+// hc for exponential-backoff re-probing.
+func (b *Backend) recordFailure(hc *HealthChecker) {
+	b.breaker.mu.Lock()
+	now := time.Now()
+	if now.Sub(b.breaker.windowStart) > failureWindow {
+		b.breaker.windowStart = now
+		b.breaker.failures = 0
+	}
+	b.breaker.failures++
+	tripped := b.breaker.failures >= failureThreshold
+	b.breaker.mu.Unlock()
+
+	if tripped && b.IsAlive() {
+		b.SetAlive(false)
+		log.Printf("circuit breaker: %s tripped after %d failures, probing with backoff", b.URL, failureThreshold)
+		if hc != nil {
+			hc.refreshAliveGauge()
+			hc.scheduleBackoffProbe(b)
+		}
+	}
+}
+
+// recordSuccess clears a backend's failure count and backoff.
+func (b *Backend) recordSuccess() {
+	b.breaker.mu.Lock()
+	b.breaker.failures = 0
+	b.breaker.backoff = 0
+	b.breaker.mu.Unlock()
+}
+
+// HealthChecker actively probes every backend in pool on a fixed
+// interval and flips Backend.SetAlive based on the result. It also
+// drives the exponential-backoff re-probes scheduled by recordFailure.
+type HealthChecker struct {
+	pool     *ServerPool
+	path     string
+	interval time.Duration
+	client   *http.Client
+}
+
+func NewHealthChecker(pool *ServerPool, path string, interval, timeout time.Duration) *HealthChecker {
+	return &HealthChecker{
+		pool:     pool,
+		path:     path,
+		interval: interval,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// Run probes every backend immediately, then again every hc.interval.
+// It blocks and should be started in its own goroutine.
+func (hc *HealthChecker) Run() {
+	hc.probeAll()
+	ticker := time.NewTicker(hc.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		hc.probeAll()
+	}
+}
+
+// refreshAliveGauge recomputes metrics.AliveBackends from the pool.
+func (hc *HealthChecker) refreshAliveGauge() {
+	metrics.AliveBackends.Set(float64(len(hc.pool.AliveBackends())))
+}
+
+func (hc *HealthChecker) probeAll() {
+	for _, b := range hc.pool.Backends() {
+		go hc.probe(b)
+	}
+}
+
+func (hc *HealthChecker) probe(b *Backend) {
+	wasAlive := b.IsAlive()
+	if hc.check(b) {
+		b.SetAlive(true)
+		b.recordSuccess()
+		if !wasAlive {
+			log.Printf("healthcheck: %s recovered", b.URL)
+			hc.refreshAliveGauge()
+		}
+		return
+	}
+	if wasAlive {
+		log.Printf("healthcheck: %s failed probe, marking down", b.URL)
+	}
+	b.SetAlive(false)
+	hc.refreshAliveGauge()
+}
+
+func (hc *HealthChecker) check(b *Backend) bool {
+	probePath := hc.path
+	if p := b.HealthPath(); p != "" {
+		probePath = p
+	}
+	target := *b.URL
+	target.Path = path.Join(target.Path, probePath)
+	req, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		return false
+	}
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode < http.StatusInternalServerError
+}
+
+// scheduleBackoffProbe re-probes b after an exponentially increasing
+// delay (capped at maxBackoff) until it comes back alive.
+func (hc *HealthChecker) scheduleBackoffProbe(b *Backend) {
+	b.breaker.mu.Lock()
+	if b.breaker.backoff == 0 {
+		b.breaker.backoff = initialBackoff
+	} else {
+		b.breaker.backoff *= 2
+		if b.breaker.backoff > maxBackoff {
+			b.breaker.backoff = maxBackoff
+		}
+	}
+	wait := b.breaker.backoff
+	b.breaker.mu.Unlock()
+
+	time.AfterFunc(wait, func() {
+		if b.IsAlive() {
+			return
+		}
+		if hc.check(b) {
+			b.SetAlive(true)
+			b.recordSuccess()
+			log.Printf("healthcheck: %s recovered", b.URL)
+			hc.refreshAliveGauge()
+			return
+		}
+		hc.scheduleBackoffProbe(b)
+	})
+}
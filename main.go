@@ -5,57 +5,17 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
 	"strings"
-	"sync"
-	"sync/atomic"
 	"time"
-)
-
-type Backend struct {
-	URL          *url.URL
-	Alive        bool
-	mux          sync.RWMutex
-	ReverseProxy *httputil.ReverseProxy
-}
-
-func (b *Backend) SetAlive(alive bool) {
-	b.mux.Lock()
-	b.Alive = alive
-	b.mux.Unlock()
-}
-
-func (b *Backend) IsAlive() bool {
-	b.mux.RLock()
-	defer b.mux.RUnlock()
-	return b.Alive
-}
-
-type ServerPool struct {
-	backends []*Backend
-	current  uint64
-}
-
-func (s *ServerPool) AddBackend(b *Backend) {
-	s.backends = append(s.backends, b)
-}
 
-func (s *ServerPool) NextIndex() int {
-	return int(atomic.AddUint64(&s.current, 1) % uint64(len(s.backends)))
-}
-
-var pool ServerPool
+	"github.com/Kunj125/load_balancer_go/metrics"
+)
 
-func addBackend(raw string) {
-	u, err := url.Parse(strings.TrimSpace(raw))
-	if err != nil {
-		log.Fatalf("invalid backend URL %q: %v", raw, err)
-	}
-	rp := httputil.NewSingleHostReverseProxy(u)
-	pool.AddBackend(&Backend{URL: u, Alive: true, ReverseProxy: rp})
-	log.Printf("backend added: %s", u)
-}
+var (
+	balancer      Balancer
+	healthChecker *HealthChecker
+	accessLog     *AccessLog
+)
 
 func runBackend(port int, name string, delay time.Duration) {
 	mux := http.NewServeMux()
@@ -70,47 +30,81 @@ func runBackend(port int, name string, delay time.Duration) {
 	log.Fatal(http.ListenAndServe(addr, mux))
 }
 
-func (s *ServerPool) GetNextPeer() *Backend {
-	length := len(s.backends)
-	if length == 0 {
-		return nil
+// parseWeights returns one weight per backend, aligned by position with
+// -backends. An empty raw means every backend gets weight 1.
+func parseWeights(raw string, n int) []int {
+	weights := make([]int, n)
+	for i := range weights {
+		weights[i] = 1
 	}
-	next := s.NextIndex()
-
-	for i := 0; i < length; i++ {
-		idx := (next + i) % length
-		if s.backends[idx].IsAlive() {
-			if i != 0 {
-				atomic.StoreUint64(&s.current, uint64(idx))
-			}
-			return s.backends[idx]
-		}
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return weights
 	}
-	return nil
-}
-
-func lb(w http.ResponseWriter, r *http.Request) {
-	peer := pool.GetNextPeer()
-	if peer == nil {
-		http.Error(w, "no backends available", http.StatusServiceUnavailable)
-		return
+	parts := strings.Split(raw, ",")
+	if len(parts) != n {
+		log.Fatalf("-weights must list exactly one weight per backend (%d backends, %d weights)", n, len(parts))
+	}
+	for i, p := range parts {
+		w := 0
+		if _, err := fmt.Sscanf(strings.TrimSpace(p), "%d", &w); err != nil || w <= 0 {
+			log.Fatalf("invalid weight %q", p)
+		}
+		weights[i] = w
 	}
-	log.Printf("%s %s -> %s", r.Method, r.URL.Path, peer.URL)
-	peer.ReverseProxy.ServeHTTP(w, r)
+	return weights
 }
 
 func main() {
 	var backendList string
+	var weightList string
+	var strategy string
 	var port int
 	var backendOnly bool
 	var name string
 	var delay time.Duration
+	var healthPath string
+	var healthInterval time.Duration
+	var healthTimeout time.Duration
+	var certFile string
+	var keyFile string
+	var autocertDomain string
+	var autocertCache string
+	var maxRetries int
+	var retryBudget time.Duration
+	var hedgeAfter time.Duration
+	var retryBodyCap int64
+	var accessLogPath string
+	var configPath string
+	var adminAddr string
+	var affinitySecret string
+	var hashKey string
+	var hashEpsilon float64
 
 	flag.StringVar(&backendList, "backends", "", "Comma-separated backend URLs (e.g. http://127.0.0.1:3031,http://127.0.0.1:3032)")
+	flag.StringVar(&weightList, "weights", "", "Comma-separated per-backend weights, aligned with -backends (default 1 each; used by -strategy=weighted-rr)")
+	flag.StringVar(&strategy, "strategy", "roundrobin", "Load-balancing strategy: roundrobin|weighted-rr|least-conn|ewma|p2c|sticky-cookie|consistent-hash")
 	flag.IntVar(&port, "port", 8080, "Port to listen on (LB) or for backend when -backend is set")
 	flag.BoolVar(&backendOnly, "backend", false, "Run as a simple backend instead of load balancer")
 	flag.StringVar(&name, "name", "backend", "Backend display name (when -backend is set)")
 	flag.DurationVar(&delay, "delay", 0, "Optional artificial delay for backend responses, e.g. 200ms")
+	flag.StringVar(&healthPath, "healthcheck-path", "/healthz", "Path probed on each backend for active health checks")
+	flag.DurationVar(&healthInterval, "healthcheck-interval", 10*time.Second, "Interval between active health checks")
+	flag.DurationVar(&healthTimeout, "healthcheck-timeout", 2*time.Second, "Timeout for a single active health check request")
+	flag.StringVar(&certFile, "cert", "", "TLS certificate file (enables HTTPS on -port alongside HTTP and h2c)")
+	flag.StringVar(&keyFile, "key", "", "TLS private key file, used with -cert")
+	flag.StringVar(&autocertDomain, "autocert-domain", "", "Domain to fetch a certificate for via Let's Encrypt (autocert), instead of -cert/-key")
+	flag.StringVar(&autocertCache, "autocert-cache", "autocert-cache", "Directory autocert uses to cache issued certificates")
+	flag.IntVar(&maxRetries, "max-retries", 0, "Retry a failed request against a different backend up to N times (idempotent methods only, unless Idempotency-Key is set)")
+	flag.DurationVar(&retryBudget, "retry-budget", 500*time.Millisecond, "Total deadline across all attempts of a request, including retries and hedging")
+	flag.DurationVar(&hedgeAfter, "hedge-after", 0, "If > 0, fire a second request to another backend when the first hasn't responded within this long")
+	flag.Int64Var(&retryBodyCap, "retry-body-cap", 1<<20, "Maximum request body size (bytes) buffered for replay across retries/hedging")
+	flag.StringVar(&accessLogPath, "access-log", "", "Write a structured JSON access log line per request to this path (disabled if empty)")
+	flag.StringVar(&configPath, "config", "", "YAML file listing backends (and optionally strategy); takes precedence over -backends/-weights and is hot-reloaded on change or SIGHUP")
+	flag.StringVar(&adminAddr, "admin-addr", "", "If set, serve the backend management API (POST/DELETE/PATCH /backends) on this address")
+	flag.StringVar(&affinitySecret, "affinity-secret", "", "HMAC secret signing the LB_AFFINITY cookie, required by -strategy=sticky-cookie")
+	flag.StringVar(&hashKey, "hash-key", "ip", "Request key consistent-hash hashes on: ip|header:X-Foo|path")
+	flag.Float64Var(&hashEpsilon, "hash-epsilon", 0.25, "Bounded-load slack for -strategy=consistent-hash: a backend is skipped once its inflight exceeds (1+epsilon) times the average")
 	flag.Parse()
 
 	if backendOnly {
@@ -118,35 +112,78 @@ func main() {
 		return
 	}
 
-	if backendList == "" {
-		log.Fatal("please provide -backends=http://127.0.0.1:3031[,http://127.0.0.1:3032,...]")
+	if configPath != "" {
+		cfg, err := loadConfigFile(configPath)
+		if err != nil {
+			log.Fatalf("-config: %v", err)
+		}
+		if cfg.Strategy != "" {
+			strategy = cfg.Strategy
+		}
+		applyConfig(cfg)
+		go watchConfig(configPath)
+	} else if backendList != "" {
+		var raws []string
+		for _, raw := range strings.Split(backendList, ",") {
+			raw = strings.TrimSpace(raw)
+			if raw == "" {
+				continue
+			}
+			raws = append(raws, raw)
+		}
+		weights := parseWeights(weightList, len(raws))
+		for i, raw := range raws {
+			addBackend(raw, weights[i])
+		}
+	} else {
+		log.Fatal("please provide -backends=http://127.0.0.1:3031[,http://127.0.0.1:3032,...] or -config=lb.yaml")
 	}
 
-	for _, raw := range strings.Split(backendList, ",") {
-		raw = strings.TrimSpace(raw)
-		if raw == "" {
-			continue
-		}
-		u, err := url.Parse(raw)
+	b, err := NewBalancer(strategy, &pool, BalancerOptions{
+		AffinitySecret: affinitySecret,
+		HashKey:        hashKey,
+		HashEpsilon:    hashEpsilon,
+	})
+	if err != nil {
+		log.Fatalf("-strategy: %v", err)
+	}
+	balancer = b
+
+	healthChecker = NewHealthChecker(&pool, healthPath, healthInterval, healthTimeout)
+	go healthChecker.Run()
+
+	if accessLogPath != "" {
+		al, err := NewAccessLog(accessLogPath)
 		if err != nil {
-			log.Fatalf("invalid backend URL %q: %v", raw, err)
+			log.Fatalf("-access-log: %v", err)
 		}
-		rp := httputil.NewSingleHostReverseProxy(u)
-		pool.AddBackend(&Backend{URL: u, Alive: true, ReverseProxy: rp})
-		log.Printf("backend added: %s", u)
+		accessLog = al
 	}
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		peer := pool.GetNextPeer()
-		if peer == nil {
-			http.Error(w, "no backends available", http.StatusServiceUnavailable)
-			return
-		}
-		log.Printf("%s %s -> %s", r.Method, r.URL.Path, peer.URL)
-		peer.ReverseProxy.ServeHTTP(w, r)
-	})
+	retryOpts := retryOptions{
+		maxRetries:  maxRetries,
+		retryBudget: retryBudget,
+		hedgeAfter:  hedgeAfter,
+		bodyCap:     retryBodyCap,
+	}
+	http.HandleFunc("/", retryHandler(retryOpts))
+	http.HandleFunc("/lb/health", healthStatusHandler)
+	http.Handle("/metrics", metrics.Handler())
+
+	if adminAddr != "" {
+		go func() {
+			log.Printf("admin API listening on %s", adminAddr)
+			log.Fatal(http.ListenAndServe(adminAddr, NewAdminMux()))
+		}()
+	}
 
 	addr := fmt.Sprintf(":%d", port)
-	log.Printf("load balancer listening on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	log.Printf("load balancer listening on %s (strategy=%s)", addr, strategy)
+	opts := serveOptions{
+		certFile:       certFile,
+		keyFile:        keyFile,
+		autocertDomain: autocertDomain,
+		autocertCache:  autocertCache,
+	}
+	log.Fatal(serve(addr, http.DefaultServeMux, opts))
 }
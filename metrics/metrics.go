@@ -0,0 +1,66 @@
+// Package metrics holds the Prometheus collectors the load balancer
+// reports on /metrics.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// RequestsTotal counts completed backend attempts by backend and
+	// response status class (2xx/3xx/4xx/5xx).
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lb_requests_total",
+		Help: "Total backend attempts, by backend and response status class.",
+	}, []string{"backend", "status_class"})
+
+	// UpstreamLatency observes how long a single backend attempt took.
+	UpstreamLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lb_upstream_latency_seconds",
+		Help:    "Latency of a single backend attempt.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"backend"})
+
+	// TotalLatency observes end-to-end request latency, including any
+	// retries and hedging.
+	TotalLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "lb_total_latency_seconds",
+		Help:    "End-to-end request latency, including retries and hedging.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// AliveBackends reports how many backends are currently marked alive.
+	AliveBackends = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "lb_alive_backends",
+		Help: "Number of backends currently marked alive.",
+	})
+
+	// Inflight reports requests currently dispatched to each backend.
+	Inflight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lb_inflight_requests",
+		Help: "Requests currently in flight to a backend.",
+	}, []string{"backend"})
+)
+
+// Handler serves the Prometheus exposition format for /metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// StatusClass buckets an HTTP status code into "2xx".."5xx".
+func StatusClass(code int) string {
+	switch {
+	case code >= 500:
+		return "5xx"
+	case code >= 400:
+		return "4xx"
+	case code >= 300:
+		return "3xx"
+	default:
+		return "2xx"
+	}
+}
@@ -0,0 +1,151 @@
+// Package mux lets a single net.Listener serve plain HTTP, TLS (including
+// HTTP/2 negotiated via ALPN) and HTTP/2 prior-knowledge (h2c) on one
+// port by sniffing the first bytes of each connection before handing it
+// off to the matching protocol listener.
+package mux
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"sync"
+)
+
+// h2cPreface is the connection preface an h2c client sends before any
+// TLS or HTTP/1.x bytes, per RFC 7540 3.4.
+const h2cPreface = "PRI * HTTP/2.0"
+
+// tlsHandshakeRecordType is the first byte of a TLS record carrying a
+// ClientHello.
+const tlsHandshakeRecordType = 0x16
+
+// Mux multiplexes connections accepted from a root listener across
+// three protocol-specific listeners based on their first bytes.
+type Mux struct {
+	root net.Listener
+
+	httpLn *protoListener
+	tlsLn  *protoListener
+	h2cLn  *protoListener
+}
+
+// New wraps root so its connections can be dispatched by protocol. Call
+// Serve to start accepting, then pass HTTPListener/TLSListener/H2CListener
+// to separate http.Serve calls.
+func New(root net.Listener) *Mux {
+	return &Mux{
+		root:   root,
+		httpLn: newProtoListener(root.Addr()),
+		tlsLn:  newProtoListener(root.Addr()),
+		h2cLn:  newProtoListener(root.Addr()),
+	}
+}
+
+// HTTPListener carries plain HTTP/1.x connections.
+func (m *Mux) HTTPListener() net.Listener { return m.httpLn }
+
+// TLSListener carries connections that begin with a TLS ClientHello.
+func (m *Mux) TLSListener() net.Listener { return m.tlsLn }
+
+// H2CListener carries HTTP/2 prior-knowledge (h2c) connections.
+func (m *Mux) H2CListener() net.Listener { return m.h2cLn }
+
+// Serve accepts connections from the root listener and dispatches each to
+// the matching protocol listener. It blocks until the root listener is
+// closed, and then closes every protocol listener with that error.
+func (m *Mux) Serve() error {
+	for {
+		conn, err := m.root.Accept()
+		if err != nil {
+			m.httpLn.closeWithError(err)
+			m.tlsLn.closeWithError(err)
+			m.h2cLn.closeWithError(err)
+			return err
+		}
+		go m.dispatch(conn)
+	}
+}
+
+func (m *Mux) dispatch(conn net.Conn) {
+	br := bufio.NewReaderSize(conn, len(h2cPreface))
+	sc := &sniffedConn{Conn: conn, r: br}
+
+	first, err := br.Peek(1)
+	if err != nil || len(first) == 0 {
+		sc.Close()
+		return
+	}
+
+	if first[0] == tlsHandshakeRecordType {
+		m.tlsLn.submit(sc)
+		return
+	}
+
+	preface, _ := br.Peek(len(h2cPreface))
+	if bytes.HasPrefix(preface, []byte(h2cPreface)) {
+		m.h2cLn.submit(sc)
+		return
+	}
+
+	m.httpLn.submit(sc)
+}
+
+// sniffedConn replays the bytes peeked while sniffing before falling
+// back to reading directly from the underlying connection.
+type sniffedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *sniffedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// protoListener is a net.Listener fed by Mux.dispatch rather than by
+// accepting directly on a socket.
+type protoListener struct {
+	addr   net.Addr
+	connCh chan net.Conn
+	done   chan struct{}
+	once   sync.Once
+	err    error
+}
+
+func newProtoListener(addr net.Addr) *protoListener {
+	return &protoListener{
+		addr:   addr,
+		connCh: make(chan net.Conn),
+		done:   make(chan struct{}),
+	}
+}
+
+func (l *protoListener) submit(conn net.Conn) {
+	select {
+	case l.connCh <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *protoListener) closeWithError(err error) {
+	l.once.Do(func() {
+		l.err = err
+		close(l.done)
+	})
+}
+
+func (l *protoListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.connCh:
+		return conn, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+func (l *protoListener) Close() error {
+	l.closeWithError(net.ErrClosed)
+	return nil
+}
+
+func (l *protoListener) Addr() net.Addr { return l.addr }
@@ -0,0 +1,64 @@
+package main
+
+import "sync"
+
+// ServerPool holds the set of backends the load balancer can route to.
+// Backends can be added, removed, and iterated concurrently: active
+// health checks, the admin API, and config reloads all mutate it from
+// their own goroutines while requests are being balanced.
+type ServerPool struct {
+	mu       sync.RWMutex
+	backends []*Backend
+}
+
+var pool ServerPool
+
+func (s *ServerPool) AddBackend(b *Backend) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backends = append(s.backends, b)
+}
+
+// RemoveBackend drops b from the pool. It is a no-op if b isn't in it.
+func (s *ServerPool) RemoveBackend(b *Backend) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.backends {
+		if existing == b {
+			s.backends = append(s.backends[:i:i], s.backends[i+1:]...)
+			return
+		}
+	}
+}
+
+// Backends returns a snapshot of every backend in the pool, alive or not.
+func (s *ServerPool) Backends() []*Backend {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*Backend, len(s.backends))
+	copy(out, s.backends)
+	return out
+}
+
+// AliveBackends returns the backends eligible to receive new requests:
+// alive and not draining.
+func (s *ServerPool) AliveBackends() []*Backend {
+	all := s.Backends()
+	alive := make([]*Backend, 0, len(all))
+	for _, b := range all {
+		if b.IsAlive() && !b.IsDraining() {
+			alive = append(alive, b)
+		}
+	}
+	return alive
+}
+
+// Find returns the backend whose URL stringifies to rawURL, or nil.
+func (s *ServerPool) Find(rawURL string) *Backend {
+	for _, b := range s.Backends() {
+		if b.URL.String() == rawURL {
+			return b
+		}
+	}
+	return nil
+}
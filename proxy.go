@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/Kunj125/load_balancer_go/metrics"
+)
+
+// backendTransport is shared by every backend's reverse proxy so that
+// upstream connections can negotiate HTTP/2 as well as HTTP/1.1.
+var backendTransport = newBackendTransport()
+
+func newBackendTransport() *http.Transport {
+	t := &http.Transport{}
+	if err := http2.ConfigureTransport(t); err != nil {
+		log.Printf("http2: configuring backend transport: %v", err)
+	}
+	return t
+}
+
+// newBackendTransportTLS is like newBackendTransport but dials upstream
+// with tlsCfg, used for backends whose -config entry sets TLS options
+// (e.g. insecure_skip_verify for self-signed upstreams).
+func newBackendTransportTLS(tlsCfg *tls.Config) *http.Transport {
+	t := &http.Transport{TLSClientConfig: tlsCfg}
+	if err := http2.ConfigureTransport(t); err != nil {
+		log.Printf("http2: configuring backend transport: %v", err)
+	}
+	return t
+}
+
+type ctxKey int
+
+const startTimeKey ctxKey = iota
+
+func withStartTime(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), startTimeKey, time.Now()))
+}
+
+func elapsedSince(r *http.Request) time.Duration {
+	t, ok := r.Context().Value(startTimeKey).(time.Time)
+	if !ok {
+		return 0
+	}
+	return time.Since(t)
+}
+
+// addBackend parses raw as a backend URL, wires its reverse proxy so that
+// balancer.Release fires once the request completes, and adds it to the
+// global pool.
+func addBackend(raw string, weight int) {
+	addBackendSpec(backendSpec{URL: raw, Weight: weight})
+}
+
+// addBackendSpec is addBackend plus the per-backend health-check path
+// and upstream TLS options carried by a -config YAML entry.
+func addBackendSpec(spec backendSpec) {
+	u, err := url.Parse(strings.TrimSpace(spec.URL))
+	if err != nil {
+		log.Fatalf("invalid backend URL %q: %v", spec.URL, err)
+	}
+	weight := spec.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+
+	b := &Backend{URL: u, weight: int64(weight), alive: true, healthPath: spec.HealthPath}
+	rp := httputil.NewSingleHostReverseProxy(u)
+	if spec.TLS.InsecureSkipVerify || spec.TLS.ServerName != "" {
+		rp.Transport = newBackendTransportTLS(&tls.Config{
+			InsecureSkipVerify: spec.TLS.InsecureSkipVerify,
+			ServerName:         spec.TLS.ServerName,
+		})
+	} else {
+		rp.Transport = backendTransport
+	}
+	rp.ModifyResponse = func(resp *http.Response) error {
+		dur := elapsedSince(resp.Request)
+		balancer.Release(b, nil, dur)
+		metrics.UpstreamLatency.WithLabelValues(b.URL.String()).Observe(dur.Seconds())
+		metrics.RequestsTotal.WithLabelValues(b.URL.String(), metrics.StatusClass(resp.StatusCode)).Inc()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			b.recordFailure(healthChecker)
+		} else {
+			b.recordSuccess()
+		}
+		if cs, ok := balancer.(cookieSetter); ok {
+			cs.setCookie(resp, b)
+		}
+		return nil
+	}
+	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		dur := elapsedSince(r)
+		balancer.Release(b, err, dur)
+		metrics.UpstreamLatency.WithLabelValues(b.URL.String()).Observe(dur.Seconds())
+		metrics.RequestsTotal.WithLabelValues(b.URL.String(), metrics.StatusClass(http.StatusBadGateway)).Inc()
+		// A canceled context means this attempt lost a hedge race, not
+		// that the backend failed; don't penalize a healthy backend for
+		// merely being slower than its competitor.
+		if !errors.Is(err, context.Canceled) {
+			b.recordFailure(healthChecker)
+		}
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}
+	b.ReverseProxy = rp
+
+	pool.AddBackend(b)
+	log.Printf("backend added: %s (weight=%d)", u, weight)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/Kunj125/load_balancer_go/metrics"
+)
+
+// idempotentMethods may be retried or hedged without an explicit
+// Idempotency-Key header.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// retryOptions configures the retry/hedge middleware in front of the balancer.
+type retryOptions struct {
+	maxRetries  int
+	retryBudget time.Duration
+	hedgeAfter  time.Duration
+	bodyCap     int64
+}
+
+func isRetryable(r *http.Request) bool {
+	if r.Header.Get("Idempotency-Key") != "" {
+		return true
+	}
+	return idempotentMethods[r.Method]
+}
+
+// bufferBody reads r.Body (up to limit+1 bytes) so it can be replayed
+// across attempts, and replaces r.Body with a fresh reader over what it
+// read. It returns nil if the body was absent or larger than limit, in
+// which case r.Body and r.ContentLength are left intact (stitching the
+// already-read prefix back onto the untouched remainder) so the request
+// can still be sent once, unbuffered, as-is.
+func bufferBody(r *http.Request, limit int64) []byte {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	body := r.Body
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil || int64(len(data)) > limit {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), body), body}
+		return nil
+	}
+	body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	return data
+}
+
+// cloneWithBody clones r under ctx, substituting in a fresh reader over
+// body when the original request had a buffered one, and stamps the
+// clone with its own start time for EWMA accounting.
+func cloneWithBody(r *http.Request, ctx context.Context, body []byte) *http.Request {
+	req := r.Clone(ctx)
+	if body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+	return withStartTime(req)
+}
+
+// attemptResult is one backend's response, captured instead of written
+// straight to the client so a retry can still happen.
+type attemptResult struct {
+	rec *httptest.ResponseRecorder
+	dur time.Duration
+}
+
+func isFailure(res *attemptResult) bool {
+	return res == nil || res.rec.Code >= http.StatusInternalServerError
+}
+
+// dispatch proxies req to peer, via the usual ReverseProxy (so EWMA,
+// inflight and circuit-breaker bookkeeping still fire), capturing the
+// response instead of streaming it to the real client.
+func dispatch(peer *Backend, req *http.Request) *attemptResult {
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	peer.ReverseProxy.ServeHTTP(rec, req)
+	return &attemptResult{rec: rec, dur: time.Since(start)}
+}
+
+func writeResult(w http.ResponseWriter, res *attemptResult) {
+	header := w.Header()
+	for k, vs := range res.rec.Header() {
+		header[k] = vs
+	}
+	w.WriteHeader(res.rec.Code)
+	w.Write(res.rec.Body.Bytes())
+}
+
+// pickFallback returns an alive backend not already in tried, preferring
+// the least loaded one, and marks it as picked via trackPick so its
+// inflight count stays balanced with the Release call its dispatch will
+// trigger. It returns nil if every alive backend has been tried.
+func pickFallback(tried map[*Backend]bool) *Backend {
+	var best *Backend
+	for _, b := range pool.AliveBackends() {
+		if tried[b] {
+			continue
+		}
+		if best == nil || b.Inflight() < best.Inflight() {
+			best = b
+		}
+	}
+	return trackPick(best)
+}
+
+// hedgedDispatch sends req to primary and, if it hasn't finished within
+// opts.hedgeAfter, also sends a copy to a second untried backend. It
+// returns whichever attempt finishes first with a non-failure response,
+// falling back to whichever finishes first at all, and cancels the
+// loser's context.
+func hedgedDispatch(ctx context.Context, primary *Backend, r *http.Request, body []byte, tried map[*Backend]bool, opts retryOptions) *attemptResult {
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	defer cancelPrimary()
+
+	primaryCh := make(chan *attemptResult, 1)
+	go func() { primaryCh <- dispatch(primary, cloneWithBody(r, primaryCtx, body)) }()
+
+	if opts.hedgeAfter <= 0 {
+		return <-primaryCh
+	}
+
+	select {
+	case res := <-primaryCh:
+		return res
+	case <-ctx.Done():
+		return <-primaryCh
+	case <-time.After(opts.hedgeAfter):
+	}
+
+	hedgePeer := pickFallback(tried)
+	if hedgePeer == nil {
+		return <-primaryCh
+	}
+	tried[hedgePeer] = true
+
+	hedgeCtx, cancelHedge := context.WithCancel(ctx)
+	defer cancelHedge()
+	hedgeCh := make(chan *attemptResult, 1)
+	go func() { hedgeCh <- dispatch(hedgePeer, cloneWithBody(r, hedgeCtx, body)) }()
+
+	select {
+	case res := <-primaryCh:
+		if !isFailure(res) {
+			cancelHedge()
+			return res
+		}
+		return <-hedgeCh
+	case res := <-hedgeCh:
+		if !isFailure(res) {
+			cancelPrimary()
+			return res
+		}
+		return <-primaryCh
+	}
+}
+
+// retryHandler wraps the balancer with retries against a different
+// backend on 5xx/transport failures (bounded by -max-retries and a
+// total -retry-budget deadline) and, on the first attempt, an optional
+// hedge request. Only idempotent methods are retried or hedged unless
+// the request carries an Idempotency-Key header.
+func retryHandler(opts retryOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		retryable := isRetryable(r)
+
+		var body []byte
+		if retryable && (opts.maxRetries > 0 || opts.hedgeAfter > 0) {
+			body = bufferBody(r, opts.bodyCap)
+		}
+
+		ctx := r.Context()
+		if opts.retryBudget > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, opts.retryBudget)
+			defer cancel()
+		}
+
+		maxAttempts := 1
+		if retryable {
+			maxAttempts += opts.maxRetries
+		}
+
+		tried := map[*Backend]bool{}
+		var last *attemptResult
+		var lastPeer *Backend
+		attempts := 0
+
+		for attempt := 1; attempt <= maxAttempts; attempt++ {
+			if ctx.Err() != nil {
+				break
+			}
+
+			var peer *Backend
+			if attempt == 1 {
+				peer = balancer.Pick(r)
+			} else {
+				peer = pickFallback(tried)
+			}
+			if peer == nil {
+				break
+			}
+			tried[peer] = true
+			attempts = attempt
+			lastPeer = peer
+			log.Printf("%s %s -> %s (attempt %d/%d)", r.Method, r.URL.Path, peer.URL, attempt, maxAttempts)
+
+			var res *attemptResult
+			if attempt == 1 && retryable {
+				res = hedgedDispatch(ctx, peer, r, body, tried, opts)
+			} else {
+				res = dispatch(peer, cloneWithBody(r, ctx, body))
+			}
+
+			last = res
+			if !isFailure(res) {
+				writeResult(w, res)
+				logAccess(r, res, lastPeer, attempts, start)
+				return
+			}
+			log.Printf("retry: attempt %d to %s failed with %d", attempt, peer.URL, res.rec.Code)
+		}
+
+		if last != nil {
+			writeResult(w, last)
+			logAccess(r, last, lastPeer, attempts, start)
+			return
+		}
+		http.Error(w, "no backends available", http.StatusServiceUnavailable)
+		logAccess(r, nil, nil, attempts, start)
+	}
+}
+
+// logAccess records the total-latency metric and, if -access-log is
+// set, one structured JSON line for the request.
+func logAccess(r *http.Request, res *attemptResult, peer *Backend, attempts int, start time.Time) {
+	total := time.Since(start)
+	metrics.TotalLatency.Observe(total.Seconds())
+
+	entry := accessLogEntry{
+		Timestamp: start,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Status:    http.StatusServiceUnavailable,
+		TotalMs:   float64(total) / float64(time.Millisecond),
+		Retries:   attempts - 1,
+	}
+	if res != nil {
+		entry.Status = res.rec.Code
+		entry.Bytes = res.rec.Body.Len()
+		entry.UpstreamMs = float64(res.dur) / float64(time.Millisecond)
+	}
+	if peer != nil {
+		entry.Upstream = peer.URL.String()
+	}
+	if entry.Retries < 0 {
+		entry.Retries = 0
+	}
+	accessLog.log(entry)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newHedgeTestBackend starts a server that waits delay then replies with
+// status, and wraps it in a Backend whose ReverseProxy points at it.
+func newHedgeTestBackend(t *testing.T, status int, delay time.Duration) *Backend {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", srv.URL, err)
+	}
+	return &Backend{URL: u, weight: 1, alive: true, ReverseProxy: httputil.NewSingleHostReverseProxy(u)}
+}
+
+// withPoolBackends replaces the global pool with backends for the
+// duration of the test, restoring whatever was there before on cleanup.
+// hedgedDispatch and pickFallback read backends from the global pool.
+func withPoolBackends(t *testing.T, backends ...*Backend) {
+	t.Helper()
+	prev := pool.Backends()
+	pool = ServerPool{}
+	for _, b := range backends {
+		pool.AddBackend(b)
+	}
+	t.Cleanup(func() {
+		pool = ServerPool{}
+		for _, b := range prev {
+			pool.AddBackend(b)
+		}
+	})
+}
+
+func TestHedgedDispatchWaitsOnPrimaryWhenHedgeFails(t *testing.T) {
+	primary := newHedgeTestBackend(t, http.StatusOK, 80*time.Millisecond)
+	hedgePeer := newHedgeTestBackend(t, http.StatusInternalServerError, 0)
+	withPoolBackends(t, primary, hedgePeer)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	opts := retryOptions{hedgeAfter: 10 * time.Millisecond}
+
+	res := hedgedDispatch(context.Background(), primary, req, nil, map[*Backend]bool{primary: true}, opts)
+
+	if res.rec.Code != http.StatusOK {
+		t.Fatalf("hedgedDispatch() code = %d, want %d (a failing hedge must not discard the primary once it succeeds)", res.rec.Code, http.StatusOK)
+	}
+}
+
+func TestHedgedDispatchReturnsSuccessfulHedgeWithoutWaitingOnPrimary(t *testing.T) {
+	primary := newHedgeTestBackend(t, http.StatusOK, 200*time.Millisecond)
+	hedgePeer := newHedgeTestBackend(t, http.StatusOK, 0)
+	withPoolBackends(t, primary, hedgePeer)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	opts := retryOptions{hedgeAfter: 10 * time.Millisecond}
+
+	start := time.Now()
+	res := hedgedDispatch(context.Background(), primary, req, nil, map[*Backend]bool{primary: true}, opts)
+	elapsed := time.Since(start)
+
+	if res.rec.Code != http.StatusOK {
+		t.Fatalf("hedgedDispatch() code = %d, want %d", res.rec.Code, http.StatusOK)
+	}
+	if elapsed >= 150*time.Millisecond {
+		t.Fatalf("hedgedDispatch() took %v, want it to return once the hedge succeeded rather than waiting out the slow primary", elapsed)
+	}
+}
+
+func TestHedgedDispatchReturnsPrimaryWithoutWaitingWhenNoHedgeFires(t *testing.T) {
+	primary := newHedgeTestBackend(t, http.StatusOK, 0)
+	withPoolBackends(t, primary)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := hedgedDispatch(context.Background(), primary, req, nil, map[*Backend]bool{primary: true}, retryOptions{})
+
+	if res.rec.Code != http.StatusOK {
+		t.Fatalf("hedgedDispatch() code = %d, want %d", res.rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/Kunj125/load_balancer_go/mux"
+)
+
+// serveOptions configures TLS termination for the multiplexed listener.
+// Leaving every field empty disables TLS; the mux still accepts
+// connections on the TLS branch but drops them.
+type serveOptions struct {
+	certFile       string
+	keyFile        string
+	autocertDomain string
+	autocertCache  string
+}
+
+// serve listens on addr and multiplexes plain HTTP/1.x, TLS (with
+// HTTP/2 negotiated via ALPN) and h2c prior-knowledge connections to
+// handler, all on the single port. It blocks until the listener fails.
+func serve(addr string, handler http.Handler, opts serveOptions) error {
+	root, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	m := mux.New(root)
+
+	go func() {
+		if err := http.Serve(m.HTTPListener(), handler); err != nil {
+			log.Printf("http listener: %v", err)
+		}
+	}()
+	go func() {
+		h2cHandler := h2c.NewHandler(handler, &http2.Server{})
+		if err := http.Serve(m.H2CListener(), h2cHandler); err != nil {
+			log.Printf("h2c listener: %v", err)
+		}
+	}()
+
+	tlsConfig, err := buildTLSConfig(opts)
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil {
+		go drain(m.TLSListener())
+		return m.Serve()
+	}
+
+	srv := &http.Server{Handler: handler, TLSConfig: tlsConfig}
+	if err := http2.ConfigureServer(srv, &http2.Server{}); err != nil {
+		return err
+	}
+	go func() {
+		if err := srv.Serve(tls.NewListener(m.TLSListener(), srv.TLSConfig)); err != nil {
+			log.Printf("tls listener: %v", err)
+		}
+	}()
+
+	return m.Serve()
+}
+
+// buildTLSConfig returns nil if opts configures no TLS material at all.
+func buildTLSConfig(opts serveOptions) (*tls.Config, error) {
+	switch {
+	case opts.autocertDomain != "":
+		cacheDir := opts.autocertCache
+		if cacheDir == "" {
+			cacheDir = "autocert-cache"
+		}
+		mgr := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(opts.autocertDomain),
+			Cache:      autocert.DirCache(cacheDir),
+		}
+		return mgr.TLSConfig(), nil
+	case opts.certFile != "" && opts.keyFile != "":
+		cert, err := tls.LoadX509KeyPair(opts.certFile, opts.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// drain discards connections on ln without serving them; used when no
+// TLS material is configured so the TLS branch doesn't pile up unread
+// connections.
+func drain(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}
+}